@@ -2,15 +2,42 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
 	"log"
 	"net/http"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
 	"github.com/manuel-harsch/go-ping-app/internal"
+	"github.com/manuel-harsch/go-ping-app/internal/auth"
+	"github.com/manuel-harsch/go-ping-app/internal/metrics"
+	"github.com/manuel-harsch/go-ping-app/internal/pinger"
+	"github.com/manuel-harsch/go-ping-app/internal/store"
 )
 
-const configFilePath = "config.json"
+// upgrader upgrades GET /api/ping/stream to a WebSocket connection. The
+// GUI is served from the same origin, so we don't need to allow
+// cross-origin upgrades.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+const (
+	configFilePath = "config.json"
+	usersFilePath  = "users.json"
+	historyDBPath  = "ping_history.db"
+
+	// downsampleInterval is how often the store rolls old raw samples up
+	// into coarser aggregates.
+	downsampleInterval = 10 * time.Minute
+)
 
 func main() {
 	// Check if the configuration file exists
@@ -23,15 +50,78 @@ func main() {
 		}
 	}
 
-	// Load the configuration
-	cfg, err := internal.LoadConfig(configFilePath) // Call LoadConfig from internal package
+	// Load the configuration: config.json, then config.<APP_MODE>.json if
+	// APP_MODE is set, then environment variable overrides.
+	cfg, err := internal.LoadConfigLayered(internal.ModeConfigPaths(configFilePath))
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 		// If the config file is corrupted, we should exit the program with a non-zero exit code
 		os.Exit(1)
 	} else {
-		log.Printf("Loaded Config: Host=%s, CycleTime=%d, PingTimeout=%d", cfg.Host, cfg.CycleTime, cfg.PingTimeout)
+		log.Printf("Loaded Config: %d target(s)", len(cfg.Targets))
+	}
+
+	// cfg is read and mutated from multiple Gin handler goroutines (every
+	// request gets its own), so all access to it below goes through cfgMu.
+	var cfgMu sync.Mutex
+
+	// The manager owns one run goroutine per target; it's created once
+	// and started/stopped by the /api/ping/* handlers below.
+	p := pinger.New(cfg)
+
+	// Persist every result to SQLite so history survives restarts, and
+	// periodically roll old samples up into coarser aggregates.
+	historyStore, err := store.Open(historyDBPath)
+	if err != nil {
+		log.Fatalf("Failed to open history store: %v", err)
+	}
+	p.AttachStore(historyStore)
+
+	downsampleCtx, cancelDownsample := context.WithCancel(context.Background())
+	defer cancelDownsample()
+	go func() {
+		ticker := time.NewTicker(downsampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-downsampleCtx.Done():
+				return
+			case now := <-ticker.C:
+				if err := historyStore.Downsample(now); err != nil {
+					log.Printf("history store: downsample failed: %v", err)
+				}
+			}
+		}
+	}()
+
+	// Check if the users file exists
+	if _, err := os.Stat(usersFilePath); os.IsNotExist(err) {
+		log.Println("Users file not found, creating default admin account (admin/admin - change this immediately).")
+		if err := auth.SaveUsers(auth.DefaultUsers(), usersFilePath); err != nil {
+			log.Fatalf("Failed to create default users file: %v", err)
+		}
+	}
+
+	users, err := auth.LoadUsers(usersFilePath)
+	if err != nil {
+		log.Fatalf("Error loading users: %v", err)
+	}
+
+	// Sessions are signed with a random secret generated at startup, so
+	// existing sessions don't survive a restart. Set SESSION_SECRET to a
+	// fixed value to keep them alive across restarts.
+	sessionSecret := []byte(os.Getenv("SESSION_SECRET"))
+	if len(sessionSecret) == 0 {
+		sessionSecret = make([]byte, 32)
+		if _, err := rand.Read(sessionSecret); err != nil {
+			log.Fatalf("Failed to generate session secret: %v", err)
+		}
 	}
+	// The app is served over plain HTTP via router.Run below; set
+	// SESSION_SECURE=true once it's actually fronted by TLS so session
+	// cookies get the Secure flag back.
+	sessionSecure := os.Getenv("SESSION_SECURE") == "true"
+	authStore := auth.NewStore(users, sessionSecret, sessionSecure)
 
 	// Set up the Gin web server
 	router := gin.Default()
@@ -39,19 +129,56 @@ func main() {
 	// Serve static files (the GUI)
 	router.Static("/static", "./assets/static")
 
+	// Expose ping results as Prometheus metrics for scraping
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// API: Log in, starting a session cookie
+	router.POST("/api/login", func(c *gin.Context) {
+		var creds struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+		if err := c.BindJSON(&creds); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login data"})
+			return
+		}
+
+		user, err := authStore.Login(creds.Username, creds.Password)
+		if err != nil {
+			auth.AbortUnauthorized(c)
+			return
+		}
+		if err := authStore.BeginSession(c, *user); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start session"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"username": user.Username, "role": user.Role})
+	})
+
+	// API: Log out, clearing the session cookie
+	router.POST("/api/logout", func(c *gin.Context) {
+		authStore.EndSession(c)
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+	})
+
 	// API: Fetch current configuration
-	router.GET("/api/config", func(c *gin.Context) {
+	router.GET("/api/config", authStore.RequireRole(auth.RoleViewer), func(c *gin.Context) {
+		cfgMu.Lock()
+		defer cfgMu.Unlock()
 		c.JSON(http.StatusOK, cfg)
 	})
 
 	// API: Update the configuration
-	router.POST("/api/config", func(c *gin.Context) {
+	router.POST("/api/config", authStore.RequireRole(auth.RoleAdmin), func(c *gin.Context) {
 		var newConfig internal.Config
 		if err := c.BindJSON(&newConfig); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid configuration data"})
 			return
 		}
 
+		cfgMu.Lock()
+		defer cfgMu.Unlock()
+
 		// Save the new configuration to the file
 		if err := internal.SaveConfig(&newConfig, configFilePath); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
@@ -60,21 +187,211 @@ func main() {
 
 		// Update the in-memory config (so changes apply without restart)
 		cfg = &newConfig
+
+		// The running loop, if any, should pick up the new host/interval
+		// on its next cycle rather than requiring a restart.
+		p.Reload(cfg)
+
 		c.JSON(http.StatusOK, cfg)
 	})
 
 	// API: Start ping process
-	router.POST("/api/ping/start", func(c *gin.Context) {
-		// Logic to start the ping process would go here
+	router.POST("/api/ping/start", authStore.RequireRole(auth.RoleAdmin), func(c *gin.Context) {
+		// Start reads cfg.Targets, which the target handlers mutate.
+		cfgMu.Lock()
+		defer cfgMu.Unlock()
+
+		if err := p.Start(context.Background()); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{"message": "Ping started"})
 	})
 
 	// API: Stop ping process
-	router.POST("/api/ping/stop", func(c *gin.Context) {
-		// Logic to stop the ping process would go here
+	router.POST("/api/ping/stop", authStore.RequireRole(auth.RoleAdmin), func(c *gin.Context) {
+		p.Stop()
 		c.JSON(http.StatusOK, gin.H{"message": "Ping stopped"})
 	})
 
+	// API: Fetch recent ping results, optionally scoped to a single
+	// target via ?target=<id>
+	router.GET("/api/ping/history", authStore.RequireRole(auth.RoleViewer), func(c *gin.Context) {
+		if targetID := c.Query("target"); targetID != "" {
+			c.JSON(http.StatusOK, p.History(targetID))
+			return
+		}
+		c.JSON(http.StatusOK, p.HistoryAll())
+	})
+
+	// API: Fetch persisted history for a target over a time range, at a
+	// given resolution (raw|1m|1h). Defaults to the last hour, raw.
+	router.GET("/api/history", authStore.RequireRole(auth.RoleViewer), func(c *gin.Context) {
+		targetID := c.Query("target")
+		if targetID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "target is required"})
+			return
+		}
+
+		to := time.Now()
+		if raw := c.Query("to"); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to: " + err.Error()})
+				return
+			}
+			to = parsed
+		}
+
+		from := to.Add(-time.Hour)
+		if raw := c.Query("from"); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from: " + err.Error()})
+				return
+			}
+			from = parsed
+		}
+
+		resolution := store.ResolutionRaw
+		if raw := c.Query("resolution"); raw != "" {
+			resolution = store.Resolution(raw)
+		}
+
+		points, err := historyStore.Query(targetID, from, to, resolution)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query history"})
+			return
+		}
+		c.JSON(http.StatusOK, points)
+	})
+
+	// API: List configured targets
+	router.GET("/api/targets", authStore.RequireRole(auth.RoleViewer), func(c *gin.Context) {
+		cfgMu.Lock()
+		defer cfgMu.Unlock()
+		c.JSON(http.StatusOK, cfg.Targets)
+	})
+
+	// API: Add a new target
+	router.POST("/api/targets", authStore.RequireRole(auth.RoleAdmin), func(c *gin.Context) {
+		var target internal.Target
+		if err := c.BindJSON(&target); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target data"})
+			return
+		}
+
+		cfgMu.Lock()
+		defer cfgMu.Unlock()
+
+		target.ID = internal.NewTargetID()
+		cfg.Targets = append(cfg.Targets, target)
+
+		if err := internal.SaveConfig(cfg, configFilePath); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
+			return
+		}
+		p.Reload(cfg)
+		c.JSON(http.StatusCreated, target)
+	})
+
+	// API: Fetch a single target
+	router.GET("/api/targets/:id", authStore.RequireRole(auth.RoleViewer), func(c *gin.Context) {
+		cfgMu.Lock()
+		defer cfgMu.Unlock()
+
+		idx := findTargetIndex(cfg.Targets, c.Param("id"))
+		if idx == -1 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+			return
+		}
+		c.JSON(http.StatusOK, cfg.Targets[idx])
+	})
+
+	// API: Replace a target's fields
+	router.PUT("/api/targets/:id", authStore.RequireRole(auth.RoleAdmin), func(c *gin.Context) {
+		var target internal.Target
+		if err := c.BindJSON(&target); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target data"})
+			return
+		}
+
+		cfgMu.Lock()
+		defer cfgMu.Unlock()
+
+		idx := findTargetIndex(cfg.Targets, c.Param("id"))
+		if idx == -1 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+			return
+		}
+
+		target.ID = c.Param("id")
+		cfg.Targets[idx] = target
+
+		if err := internal.SaveConfig(cfg, configFilePath); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
+			return
+		}
+		p.Reload(cfg)
+		c.JSON(http.StatusOK, target)
+	})
+
+	// API: Remove a target
+	router.DELETE("/api/targets/:id", authStore.RequireRole(auth.RoleAdmin), func(c *gin.Context) {
+		cfgMu.Lock()
+		defer cfgMu.Unlock()
+
+		idx := findTargetIndex(cfg.Targets, c.Param("id"))
+		if idx == -1 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target not found"})
+			return
+		}
+		removed := cfg.Targets[idx]
+		cfg.Targets = append(cfg.Targets[:idx], cfg.Targets[idx+1:]...)
+
+		if err := internal.SaveConfig(cfg, configFilePath); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save config"})
+			return
+		}
+		p.Reload(cfg)
+
+		// Drop the target's metrics series so removed targets don't keep
+		// an orphaned series registered for the life of the process.
+		metrics.Delete(removed.ID, removed.Name)
+
+		c.JSON(http.StatusNoContent, nil)
+	})
+
+	// API: Stream live ping results over a WebSocket
+	router.GET("/api/ping/stream", authStore.RequireRole(auth.RoleViewer), func(c *gin.Context) {
+		conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+		if err != nil {
+			log.Printf("ping stream: upgrade failed: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		results, unsubscribe := p.Subscribe()
+		defer unsubscribe()
+
+		for result := range results {
+			if err := conn.WriteJSON(result); err != nil {
+				return
+			}
+		}
+	})
+
 	// Start the web server
 	router.Run(":8080")
 }
+
+// findTargetIndex returns the index of the target with the given ID, or
+// -1 if there's no match.
+func findTargetIndex(targets []internal.Target, id string) int {
+	for i, t := range targets {
+		if t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
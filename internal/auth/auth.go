@@ -0,0 +1,205 @@
+// Package auth gates the mutating parts of the config API behind a
+// session cookie and a two-tier role model: viewers can read config and
+// history, admins can also edit config and start/stop pings.
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Role is a user's access tier. Roles are ordered: Admin satisfies any
+// check that Viewer satisfies.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleAdmin  Role = "admin"
+)
+
+func (r Role) rank() int {
+	if r == RoleAdmin {
+		return 2
+	}
+	return 1
+}
+
+// User is a single login account. PasswordHash is a bcrypt hash, never
+// the plaintext password.
+type User struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Role         Role   `json:"role"`
+}
+
+const sessionName = "go-ping-app-session"
+
+// Store holds the known users and the cookie store backing sessions. It
+// is safe for concurrent use (gorilla/sessions handles its own locking).
+type Store struct {
+	users   map[string]User
+	cookies *sessions.CookieStore
+}
+
+// NewStore builds a Store from a user list and the secret key used to
+// sign session cookies. secure should be true only when the app is
+// served over HTTPS - gorilla/sessions defaults the cookie's Secure
+// flag to true, and browsers silently drop Set-Cookie responses
+// carrying Secure when the response didn't arrive over HTTPS, which
+// would otherwise make login look like it succeeds but never persist a
+// session.
+func NewStore(users []User, secret []byte, secure bool) *Store {
+	byUsername := make(map[string]User, len(users))
+	for _, u := range users {
+		byUsername[u.Username] = u
+	}
+
+	cookies := sessions.NewCookieStore(secret)
+	sameSite := http.SameSiteLaxMode
+	if secure {
+		sameSite = http.SameSiteNoneMode
+	}
+	cookies.Options = &sessions.Options{
+		Path:     "/",
+		MaxAge:   7 * 24 * 60 * 60, // 7 days
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: sameSite,
+	}
+
+	return &Store{
+		users:   byUsername,
+		cookies: cookies,
+	}
+}
+
+// LoadUsers reads the user list from a JSON file (see
+// DefaultUsers for the shape). A missing file is returned as an error;
+// LoadUsers does not create it.
+func LoadUsers(filePath string) ([]User, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	bytes, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	if err := json.Unmarshal(bytes, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// SaveUsers writes the user list to a JSON file.
+func SaveUsers(users []User, filePath string) error {
+	bytes, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, bytes, 0600)
+}
+
+// DefaultUsers returns a single bootstrap admin account, username
+// "admin" password "admin". Callers should log a warning telling
+// operators to change this before exposing the app.
+func DefaultUsers() []User {
+	hash, err := bcrypt.GenerateFromPassword([]byte("admin"), bcrypt.DefaultCost)
+	if err != nil {
+		// bcrypt.GenerateFromPassword only fails on a bad cost constant,
+		// which DefaultCost never is.
+		panic(err)
+	}
+	return []User{
+		{Username: "admin", PasswordHash: string(hash), Role: RoleAdmin},
+	}
+}
+
+// ErrInvalidCredentials is returned by Login when the username is
+// unknown or the password doesn't match.
+var ErrInvalidCredentials = errors.New("auth: invalid username or password")
+
+// Login validates a username/password pair against the known users.
+func (s *Store) Login(username, password string) (*User, error) {
+	user, ok := s.users[username]
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return &user, nil
+}
+
+// BeginSession starts a cookie session for user on c.
+func (s *Store) BeginSession(c *gin.Context, user User) error {
+	session, _ := s.cookies.Get(c.Request, sessionName)
+	session.Values["username"] = user.Username
+	session.Values["role"] = string(user.Role)
+	return session.Save(c.Request, c.Writer)
+}
+
+// EndSession clears the session cookie on c.
+func (s *Store) EndSession(c *gin.Context) error {
+	session, _ := s.cookies.Get(c.Request, sessionName)
+	session.Options.MaxAge = -1
+	return session.Save(c.Request, c.Writer)
+}
+
+// CurrentUser returns the logged-in user for c, if any.
+func (s *Store) CurrentUser(c *gin.Context) (*User, bool) {
+	session, err := s.cookies.Get(c.Request, sessionName)
+	if err != nil {
+		return nil, false
+	}
+
+	username, _ := session.Values["username"].(string)
+	if username == "" {
+		return nil, false
+	}
+	user, ok := s.users[username]
+	if !ok {
+		return nil, false
+	}
+	return &user, true
+}
+
+// RequireRole returns a Gin middleware that aborts with 401 if no
+// session is present, or 403 if the session's role doesn't meet min.
+func (s *Store) RequireRole(min Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := s.CurrentUser(c)
+		if !ok {
+			AbortUnauthorized(c)
+			return
+		}
+		if user.Role.rank() < min.rank() {
+			AbortForbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
+
+// AbortUnauthorized aborts the request with 401, for the frontend to
+// react to by prompting a login.
+func AbortUnauthorized(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+}
+
+// AbortForbidden aborts the request with 403, for the frontend to react
+// to by hiding admin-only actions.
+func AbortForbidden(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient role"})
+}
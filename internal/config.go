@@ -2,19 +2,58 @@
 package internal
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io"
 	"os"
+	"reflect"
+	"strconv"
 )
 
+// Protocol identifies how a Target is checked.
+type Protocol string
+
+const (
+	ProtocolICMP Protocol = "icmp"
+	ProtocolTCP  Protocol = "tcp"
+	ProtocolHTTP Protocol = "http"
+)
+
+// Target describes a single host to monitor. Each target is pinged on
+// its own schedule by the pinger subsystem.
+type Target struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Host        string   `json:"host" env:"PING_HOST"`
+	Protocol    Protocol `json:"protocol"` // icmp|tcp|http
+	Port        int      `json:"port,omitempty"`
+	CycleTime   int      `json:"cycle_time_seconds" env:"PING_CYCLE_TIME"` // Ping cycle time in seconds
+	PingTimeout int      `json:"ping_timeout" env:"PING_TIMEOUT"`          // Ping timeout in milliseconds
+
+	// ExpectedStatus is only used when Protocol is "http": the response
+	// status code that counts as a successful check.
+	ExpectedStatus int `json:"expected_status,omitempty"`
+}
+
 // Config represents the structure of the JSON configuration file
 type Config struct {
+	Targets []Target `json:"targets"`
+}
+
+// legacyConfig is the pre-multi-target config shape, kept around so
+// LoadConfig can migrate old config.json files on read.
+type legacyConfig struct {
 	Host        string `json:"host"`
-	CycleTime   int    `json:"cycle_time_seconds"` // Ping cycle time in seconds
-	PingTimeout int    `json:"ping_timeout"`       // Ping timeout in milliseconds
+	CycleTime   int    `json:"cycle_time_seconds"`
+	PingTimeout int    `json:"ping_timeout"`
 }
 
-// LoadConfig loads the configuration from a JSON file
+// LoadConfig loads the configuration from a JSON file. Files written by
+// older versions of the app (a single host/cycle_time/ping_timeout, no
+// "targets" key) are transparently migrated into a single-entry Targets
+// slice.
 func LoadConfig(filePath string) (*Config, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -27,12 +66,34 @@ func LoadConfig(filePath string) (*Config, error) {
 		return nil, err
 	}
 
-	var config Config
-	err = json.Unmarshal(bytes, &config)
-	if err != nil {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(bytes, &raw); err != nil {
 		return nil, err
 	}
 
+	if _, hasTargets := raw["targets"]; !hasTargets {
+		var legacy legacyConfig
+		if err := json.Unmarshal(bytes, &legacy); err != nil {
+			return nil, err
+		}
+		return &Config{
+			Targets: []Target{
+				{
+					ID:          NewTargetID(),
+					Name:        legacy.Host,
+					Host:        legacy.Host,
+					Protocol:    ProtocolICMP,
+					CycleTime:   legacy.CycleTime,
+					PingTimeout: legacy.PingTimeout,
+				},
+			},
+		}, nil
+	}
+
+	var config Config
+	if err := json.Unmarshal(bytes, &config); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
@@ -51,10 +112,123 @@ func SaveConfig(config *Config, filePath string) error {
 	return nil
 }
 
+// DefaultConfig returns a config with a single default target (Google
+// Public DNS over ICMP), used the first time the app runs without a
+// config.json on disk.
 func DefaultConfig() *Config {
 	return &Config{
-		Host:        "8.8.8.8", // Default to Google Public DNS
-		CycleTime:   5,         // 5 seconds between pings
-		PingTimeout: 1000,      // 1000 ms (1 second) ping timeout
+		Targets: []Target{
+			{
+				ID:          NewTargetID(),
+				Name:        "Google DNS",
+				Host:        "8.8.8.8",
+				Protocol:    ProtocolICMP,
+				CycleTime:   5,    // 5 seconds between pings
+				PingTimeout: 1000, // 1000 ms (1 second) ping timeout
+			},
+		},
+	}
+}
+
+// LoadConfigLayered resolves a config by applying paths in order, each
+// one fully replacing the previous if it exists and has at least one
+// target, on top of DefaultConfig. Missing paths are skipped rather than treated as
+// errors, so callers can pass an optional per-mode file (e.g.
+// "config.production.json") that may not exist. After the file layers
+// are applied, environment variables bound via `env:"..."` struct tags
+// on Target are applied on top.
+//
+// PING_HOST/PING_CYCLE_TIME/PING_TIMEOUT describe a single host - the
+// shape the old single-target config file used - so they only apply
+// when the resolved config has exactly one target. Once /api/targets
+// has been used to manage more than one target, there's no target a
+// single env var could unambiguously mean, so env overrides are skipped
+// rather than silently landing on whichever target ends up at index 0.
+func LoadConfigLayered(paths []string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	for _, path := range paths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		layer, err := LoadConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", path, err)
+		}
+		if len(layer.Targets) > 0 {
+			cfg = layer
+		}
+	}
+
+	if len(cfg.Targets) == 1 {
+		applyEnvOverrides(&cfg.Targets[0])
+	}
+
+	return cfg, nil
+}
+
+// ModeConfigPaths builds the layer list for LoadConfigLayered: the base
+// config file, followed by a mode-specific file named
+// "config.<mode>.json" when APP_MODE is set.
+func ModeConfigPaths(baseConfigPath string) []string {
+	paths := []string{baseConfigPath}
+
+	mode := os.Getenv("APP_MODE")
+	if mode == "" {
+		return paths
+	}
+
+	ext := ""
+	base := baseConfigPath
+	if idx := len(baseConfigPath) - len(".json"); idx > 0 && baseConfigPath[idx:] == ".json" {
+		base = baseConfigPath[:idx]
+		ext = ".json"
+	}
+	return append(paths, fmt.Sprintf("%s.%s%s", base, mode, ext))
+}
+
+// applyEnvOverrides binds environment variables onto target's string and
+// int fields using their `env:"..."` struct tags, overriding whatever
+// value the file layers set. Unset environment variables leave the
+// field untouched.
+func applyEnvOverrides(target *Target) {
+	v := reflect.ValueOf(target).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("env")
+		if tag == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(tag)
+		if !ok {
+			continue
+		}
+
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(raw)
+		case reflect.Int:
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				continue
+			}
+			field.SetInt(int64(n))
+		}
+	}
+}
+
+// NewTargetID generates a short random identifier for a Target. It's
+// exported so callers creating targets outside this package (e.g. the
+// POST /api/targets handler) can assign IDs the same way LoadConfig and
+// DefaultConfig do.
+func NewTargetID() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand failing is effectively unrecoverable-rare; fall back
+		// to a fixed id rather than panicking the whole app.
+		return "00000000"
 	}
+	return hex.EncodeToString(b)
 }
@@ -0,0 +1,71 @@
+// Package metrics holds the Prometheus collectors for ping results,
+// registered once at package init and updated by the pinger subsystem on
+// every cycle. cmd/main.go exposes them over /metrics via promhttp.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// labelNames are target_id (stable, used to key the series) and target
+// (the human-readable name, so operators can build dashboards/alerts
+// without decoding IDs).
+var labelNames = []string{"target_id", "target"}
+
+var (
+	// PingSuccessTotal counts successful checks per target.
+	PingSuccessTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ping_success_total",
+		Help: "Total number of successful ping checks, per target.",
+	}, labelNames)
+
+	// PingFailureTotal counts failed checks per target.
+	PingFailureTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ping_failure_total",
+		Help: "Total number of failed ping checks, per target.",
+	}, labelNames)
+
+	// PingRTTSeconds observes round-trip time for successful checks.
+	PingRTTSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ping_rtt_seconds",
+		Help:    "Round-trip time of successful ping checks, per target.",
+		Buckets: prometheus.DefBuckets,
+	}, labelNames)
+
+	// PingLastStatus is 1 if the most recent check for a target
+	// succeeded, 0 otherwise.
+	PingLastStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ping_last_status",
+		Help: "Whether the most recent ping check succeeded (1) or failed (0), per target.",
+	}, labelNames)
+)
+
+func init() {
+	prometheus.MustRegister(PingSuccessTotal, PingFailureTotal, PingRTTSeconds, PingLastStatus)
+}
+
+// Observe records the outcome of a single ping check against the target
+// identified by targetID/targetName into the registered collectors.
+func Observe(targetID, targetName string, success bool, rtt time.Duration) {
+	if success {
+		PingSuccessTotal.WithLabelValues(targetID, targetName).Inc()
+		PingRTTSeconds.WithLabelValues(targetID, targetName).Observe(rtt.Seconds())
+		PingLastStatus.WithLabelValues(targetID, targetName).Set(1)
+		return
+	}
+	PingFailureTotal.WithLabelValues(targetID, targetName).Inc()
+	PingLastStatus.WithLabelValues(targetID, targetName).Set(0)
+}
+
+// Delete removes every series for targetID/targetName from the
+// registered collectors. Callers should invoke this when a target is
+// removed from the config, so deleted targets don't keep an orphaned
+// series registered for the life of the process.
+func Delete(targetID, targetName string) {
+	PingSuccessTotal.DeleteLabelValues(targetID, targetName)
+	PingFailureTotal.DeleteLabelValues(targetID, targetName)
+	PingRTTSeconds.DeleteLabelValues(targetID, targetName)
+	PingLastStatus.DeleteLabelValues(targetID, targetName)
+}
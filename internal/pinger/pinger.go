@@ -0,0 +1,354 @@
+// Package pinger implements the background ping loops that back the
+// /api/ping/* endpoints: a Manager runs one loop per configured target,
+// keeps a per-target result history ring buffer, and fans results out
+// to any live WebSocket subscribers.
+package pinger
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	probing "github.com/prometheus-community/pro-bing"
+
+	"github.com/manuel-harsch/go-ping-app/internal"
+	"github.com/manuel-harsch/go-ping-app/internal/metrics"
+)
+
+// historySize is the number of past results kept in memory, per target,
+// for GET /api/ping/history.
+const historySize = 200
+
+// Result is a single ping outcome for one target, either from an ICMP
+// echo, a TCP dial, or an HTTP GET, depending on the target's protocol.
+type Result struct {
+	TargetID  string        `json:"target_id"`
+	Timestamp time.Time     `json:"timestamp"`
+	RTT       time.Duration `json:"rtt_ns"`
+	Success   bool          `json:"success"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// Recorder persists a ping result for long-term storage, independent of
+// the in-memory history kept by Manager. internal/store.Store
+// implements this.
+type Recorder interface {
+	Record(targetID string, ts time.Time, rtt time.Duration, success bool, errMsg string) error
+}
+
+// Manager runs one ping loop per target in internal.Config and owns
+// their shared history and subscriber fan-out. It is safe for
+// concurrent use.
+type Manager struct {
+	mu      sync.Mutex
+	cfg     *internal.Config
+	runners map[string]*runner // keyed by Target.ID
+	store   Recorder
+
+	subMu       sync.Mutex
+	subscribers map[chan Result]struct{}
+}
+
+// New creates a Manager bound to cfg. No loops are started until Start
+// is called.
+func New(cfg *internal.Config) *Manager {
+	return &Manager{
+		cfg:         cfg,
+		runners:     make(map[string]*runner),
+		subscribers: make(map[chan Result]struct{}),
+	}
+}
+
+// Start launches one loop per configured target. Calling Start again
+// while already running starts nothing and returns an error; use Reload
+// to pick up target changes instead.
+func (m *Manager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.runners) > 0 {
+		return fmt.Errorf("pinger: already running")
+	}
+	for _, target := range m.cfg.Targets {
+		m.startRunnerLocked(ctx, target)
+	}
+	return nil
+}
+
+// Stop cancels every running loop.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, r := range m.runners {
+		r.cancel()
+		delete(m.runners, id)
+	}
+}
+
+// AttachStore makes the manager persist every result through r, in
+// addition to keeping it in the in-memory history. It must be called
+// before Start to cover results from the very first cycle.
+func (m *Manager) AttachStore(r Recorder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.store = r
+}
+
+// Running reports whether any loop is currently active.
+func (m *Manager) Running() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.runners) > 0
+}
+
+// Reload replaces the target set. Loops for removed targets are
+// stopped, loops for new targets are started (if the manager is
+// currently running), and loops for targets that are still present (same
+// ID) pick up the new host/interval on their next cycle without a restart.
+func (m *Manager) Reload(cfg *internal.Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cfg = cfg
+	running := len(m.runners) > 0
+
+	seen := make(map[string]struct{}, len(cfg.Targets))
+	for _, target := range cfg.Targets {
+		seen[target.ID] = struct{}{}
+		if r, ok := m.runners[target.ID]; ok {
+			r.setTarget(target)
+		} else if running {
+			m.startRunnerLocked(context.Background(), target)
+		}
+	}
+	for id, r := range m.runners {
+		if _, ok := seen[id]; !ok {
+			r.cancel()
+			delete(m.runners, id)
+		}
+	}
+}
+
+func (m *Manager) startRunnerLocked(ctx context.Context, target internal.Target) {
+	runCtx, cancel := context.WithCancel(ctx)
+	r := &runner{target: target, cancel: cancel}
+	m.runners[target.ID] = r
+	go r.run(runCtx, m)
+}
+
+// History returns a copy of the most recent results for a target,
+// oldest first.
+func (m *Manager) History(targetID string) []Result {
+	m.mu.Lock()
+	r, ok := m.runners[targetID]
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return r.history()
+}
+
+// HistoryAll returns a copy of the most recent results for every known
+// target, keyed by target ID.
+func (m *Manager) HistoryAll() map[string][]Result {
+	m.mu.Lock()
+	runners := make([]*runner, 0, len(m.runners))
+	for _, r := range m.runners {
+		runners = append(runners, r)
+	}
+	m.mu.Unlock()
+
+	out := make(map[string][]Result, len(runners))
+	for _, r := range runners {
+		out[r.target.ID] = r.history()
+	}
+	return out
+}
+
+// Subscribe registers a channel that receives every result, across all
+// targets, as it's produced. The caller must call the returned
+// unsubscribe func when done (e.g. when the WebSocket connection
+// closes) to avoid leaking the channel.
+func (m *Manager) Subscribe() (ch chan Result, unsubscribe func()) {
+	ch = make(chan Result, 16)
+	m.subMu.Lock()
+	m.subscribers[ch] = struct{}{}
+	m.subMu.Unlock()
+
+	return ch, func() {
+		m.subMu.Lock()
+		delete(m.subscribers, ch)
+		m.subMu.Unlock()
+		close(ch)
+	}
+}
+
+func (m *Manager) broadcast(r Result) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for ch := range m.subscribers {
+		select {
+		case ch <- r:
+		default:
+			// Subscriber is behind; drop the result rather than block the
+			// ping loop.
+		}
+	}
+}
+
+// runner drives the ping loop for a single target.
+type runner struct {
+	mu      sync.Mutex
+	target  internal.Target
+	results []Result
+	cancel  context.CancelFunc
+}
+
+func (r *runner) setTarget(t internal.Target) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.target = t
+}
+
+func (r *runner) history() []Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Result, len(r.results))
+	copy(out, r.results)
+	return out
+}
+
+func (r *runner) record(res Result) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, res)
+	if len(r.results) > historySize {
+		r.results = r.results[len(r.results)-historySize:]
+	}
+}
+
+func (r *runner) run(ctx context.Context, m *Manager) {
+	for {
+		r.mu.Lock()
+		target := r.target
+		r.mu.Unlock()
+
+		cycle := time.Duration(target.CycleTime) * time.Second
+		timeout := time.Duration(target.PingTimeout) * time.Millisecond
+
+		result := check(ctx, target, timeout)
+		r.record(result)
+		m.broadcast(result)
+		metrics.Observe(target.ID, target.Name, result.Success, result.RTT)
+
+		m.mu.Lock()
+		recorder := m.store
+		m.mu.Unlock()
+		if recorder != nil {
+			if err := recorder.Record(target.ID, result.Timestamp, result.RTT, result.Success, result.Error); err != nil {
+				log.Printf("pinger: failed to persist result for %s: %v", target.ID, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cycle):
+		}
+	}
+}
+
+// check performs a single reachability check against target, dispatching
+// on its Protocol.
+func check(ctx context.Context, target internal.Target, timeout time.Duration) Result {
+	switch target.Protocol {
+	case internal.ProtocolTCP:
+		return tcpCheck(ctx, target, timeout)
+	case internal.ProtocolHTTP:
+		return httpCheck(ctx, target, timeout)
+	default:
+		return icmpCheck(ctx, target, timeout)
+	}
+}
+
+// icmpCheck performs an unprivileged ICMP echo (a UDP socket, no
+// CAP_NET_RAW / root required), falling back to a TCP dial (the
+// target's Port, or 80 if unset) when that fails, e.g. the platform doesn't support unprivileged ICMP
+// at all.
+func icmpCheck(ctx context.Context, target internal.Target, timeout time.Duration) Result {
+	now := time.Now()
+
+	pinger, err := probing.NewPinger(target.Host)
+	if err == nil {
+		pinger.Count = 1
+		pinger.Timeout = timeout
+		pinger.SetPrivileged(false)
+
+		if runErr := pinger.RunWithContext(ctx); runErr == nil {
+			stats := pinger.Statistics()
+			if stats.PacketsRecv > 0 {
+				return Result{TargetID: target.ID, Timestamp: now, RTT: stats.AvgRtt, Success: true}
+			}
+			return Result{TargetID: target.ID, Timestamp: now, Success: false, Error: "no reply"}
+		}
+	}
+
+	return tcpCheck(ctx, target, timeout)
+}
+
+func tcpCheck(ctx context.Context, target internal.Target, timeout time.Duration) Result {
+	start := time.Now()
+
+	port := target.Port
+	if port == 0 {
+		port = 80
+	}
+
+	d := net.Dialer{Timeout: timeout}
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(target.Host, fmt.Sprint(port)))
+	if err != nil {
+		return Result{TargetID: target.ID, Timestamp: start, Success: false, Error: err.Error()}
+	}
+	defer conn.Close()
+
+	return Result{TargetID: target.ID, Timestamp: start, RTT: time.Since(start), Success: true}
+}
+
+func httpCheck(ctx context.Context, target internal.Target, timeout time.Duration) Result {
+	start := time.Now()
+
+	client := &http.Client{Timeout: timeout}
+	reqCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, target.Host, nil)
+	if err != nil {
+		return Result{TargetID: target.ID, Timestamp: start, Success: false, Error: err.Error()}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{TargetID: target.ID, Timestamp: start, Success: false, Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	expected := target.ExpectedStatus
+	if expected == 0 {
+		expected = http.StatusOK
+	}
+	if resp.StatusCode != expected {
+		return Result{
+			TargetID:  target.ID,
+			Timestamp: start,
+			RTT:       time.Since(start),
+			Success:   false,
+			Error:     fmt.Sprintf("unexpected status %d (want %d)", resp.StatusCode, expected),
+		}
+	}
+
+	return Result{TargetID: target.ID, Timestamp: start, RTT: time.Since(start), Success: true}
+}
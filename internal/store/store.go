@@ -0,0 +1,291 @@
+// Package store persists ping results in a SQLite database so history
+// survives restarts, with a background job that rolls old raw samples
+// up into coarser aggregates to keep the database small.
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Resolution selects which table a history query reads from.
+type Resolution string
+
+const (
+	ResolutionRaw     Resolution = "raw"
+	Resolution1Minute Resolution = "1m"
+	Resolution1Hour   Resolution = "1h"
+)
+
+// Retention windows for the downsampling job: raw samples older than
+// rawRetention are rolled into 1-minute buckets, and 1-minute buckets
+// older than oneMinuteRetention are rolled into 1-hour buckets.
+const (
+	rawRetention       = 24 * time.Hour
+	oneMinuteRetention = 7 * 24 * time.Hour
+)
+
+// Point is a single entry in a history series, raw or aggregated. For a
+// raw point, SuccessCount/FailureCount are 1/0 or 0/1 and AvgRTTMillis
+// is that sample's RTT.
+type Point struct {
+	Timestamp    time.Time `json:"timestamp"`
+	AvgRTTMillis float64   `json:"avg_rtt_ms"`
+	SuccessCount int       `json:"success_count"`
+	FailureCount int       `json:"failure_count"`
+}
+
+// Store wraps the SQLite database holding ping history.
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (creating if necessary) the SQLite database at path and
+// ensures its schema exists.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) migrate() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS raw_results (
+			target_id TEXT NOT NULL,
+			ts        INTEGER NOT NULL, -- unix nanoseconds
+			rtt_ns    INTEGER NOT NULL,
+			success   INTEGER NOT NULL, -- 0 or 1
+			error     TEXT
+		);
+		CREATE INDEX IF NOT EXISTS idx_raw_results_target_ts ON raw_results(target_id, ts);
+
+		CREATE TABLE IF NOT EXISTS agg_1m (
+			target_id     TEXT NOT NULL,
+			bucket_ts     INTEGER NOT NULL, -- unix nanoseconds, truncated to the minute
+			avg_rtt_ns    INTEGER NOT NULL,
+			success_count INTEGER NOT NULL,
+			failure_count INTEGER NOT NULL,
+			PRIMARY KEY (target_id, bucket_ts)
+		);
+
+		CREATE TABLE IF NOT EXISTS agg_1h (
+			target_id     TEXT NOT NULL,
+			bucket_ts     INTEGER NOT NULL, -- unix nanoseconds, truncated to the hour
+			avg_rtt_ns    INTEGER NOT NULL,
+			success_count INTEGER NOT NULL,
+			failure_count INTEGER NOT NULL,
+			PRIMARY KEY (target_id, bucket_ts)
+		);
+	`)
+	return err
+}
+
+// Close closes the underlying database.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record persists a single ping result. It implements pinger.Recorder.
+func (s *Store) Record(targetID string, ts time.Time, rtt time.Duration, success bool, errMsg string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO raw_results (target_id, ts, rtt_ns, success, error) VALUES (?, ?, ?, ?, ?)`,
+		targetID, ts.UnixNano(), rtt.Nanoseconds(), boolToInt(success), errMsg,
+	)
+	return err
+}
+
+// Query returns the history series for targetID between from and to
+// (inclusive), at the requested resolution, oldest first.
+func (s *Store) Query(targetID string, from, to time.Time, resolution Resolution) ([]Point, error) {
+	switch resolution {
+	case Resolution1Minute:
+		return s.queryAggregate("agg_1m", targetID, from, to)
+	case Resolution1Hour:
+		return s.queryAggregate("agg_1h", targetID, from, to)
+	default:
+		return s.queryRaw(targetID, from, to)
+	}
+}
+
+func (s *Store) queryRaw(targetID string, from, to time.Time) ([]Point, error) {
+	rows, err := s.db.Query(
+		`SELECT ts, rtt_ns, success FROM raw_results
+		 WHERE target_id = ? AND ts BETWEEN ? AND ?
+		 ORDER BY ts ASC`,
+		targetID, from.UnixNano(), to.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []Point
+	for rows.Next() {
+		var ts, rttNs, success int64
+		if err := rows.Scan(&ts, &rttNs, &success); err != nil {
+			return nil, err
+		}
+		p := Point{Timestamp: time.Unix(0, ts), AvgRTTMillis: float64(rttNs) / float64(time.Millisecond)}
+		if success != 0 {
+			p.SuccessCount = 1
+		} else {
+			p.FailureCount = 1
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+func (s *Store) queryAggregate(table, targetID string, from, to time.Time) ([]Point, error) {
+	rows, err := s.db.Query(
+		fmt.Sprintf(
+			`SELECT bucket_ts, avg_rtt_ns, success_count, failure_count FROM %s
+			 WHERE target_id = ? AND bucket_ts BETWEEN ? AND ?
+			 ORDER BY bucket_ts ASC`, table),
+		targetID, from.UnixNano(), to.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []Point
+	for rows.Next() {
+		var bucketTS, avgRTTNs int64
+		var successCount, failureCount int
+		if err := rows.Scan(&bucketTS, &avgRTTNs, &successCount, &failureCount); err != nil {
+			return nil, err
+		}
+		points = append(points, Point{
+			Timestamp:    time.Unix(0, bucketTS),
+			AvgRTTMillis: float64(avgRTTNs) / float64(time.Millisecond),
+			SuccessCount: successCount,
+			FailureCount: failureCount,
+		})
+	}
+	return points, rows.Err()
+}
+
+// Downsample rolls raw samples older than rawRetention into 1-minute
+// buckets, then 1-minute buckets older than oneMinuteRetention into
+// 1-hour buckets, deleting the source rows as it goes. It does a single
+// pass; cmd/main.go calls it periodically from a ticker goroutine.
+func (s *Store) Downsample(now time.Time) error {
+	if err := s.rollUp(
+		"raw_results", "ts", "rtt_ns", "success",
+		"agg_1m", int64(time.Minute),
+		now.Add(-rawRetention).UnixNano(),
+	); err != nil {
+		return fmt.Errorf("store: rolling up raw results: %w", err)
+	}
+
+	if err := s.rollUp(
+		"agg_1m", "bucket_ts", "avg_rtt_ns", "",
+		"agg_1h", int64(time.Hour),
+		now.Add(-oneMinuteRetention).UnixNano(),
+	); err != nil {
+		return fmt.Errorf("store: rolling up 1-minute aggregates: %w", err)
+	}
+
+	return nil
+}
+
+// rollUp aggregates rows from srcTable into dstTable, bucketed to
+// bucketNs-wide windows, for every bucket that is entirely closed (its
+// newest row is older than cutoff), then deletes those source rows.
+// successCol is the 0/1 success column on raw rows; when rolling up an
+// already-aggregated table (which instead has success_count/failure_count
+// columns) it's passed as "" and the existing counts are summed instead.
+//
+// Only fully-closed buckets are touched - a bucket straddling cutoff is
+// left alone and picked up whole on a later run - so each bucket is
+// aggregated exactly once and INSERT OR REPLACE never overwrites a
+// partial aggregate with another partial one, which would otherwise
+// silently drop whichever portion was rolled up first.
+func (s *Store) rollUp(srcTable, tsCol, rttCol, successCol, dstTable string, bucketNs, cutoff int64) error {
+	successExpr := "SUM(" + successCol + ")"
+	failureExpr := "COUNT(*) - SUM(" + successCol + ")"
+	if successCol == "" {
+		successExpr = "SUM(success_count)"
+		failureExpr = "SUM(failure_count)"
+	}
+
+	query := fmt.Sprintf(
+		`SELECT target_id, (%s / ?) * ?, AVG(%s), %s, %s
+		 FROM %s
+		 GROUP BY target_id, (%s / ?)
+		 HAVING MAX(%s) < ?`,
+		tsCol, rttCol, successExpr, failureExpr, srcTable, tsCol, tsCol,
+	)
+
+	rows, err := s.db.Query(query, bucketNs, bucketNs, bucketNs, cutoff)
+	if err != nil {
+		return err
+	}
+
+	type bucket struct {
+		targetID                   string
+		bucketTS                   int64
+		avgRTTNs                   float64
+		successCount, failureCount int
+	}
+	var buckets []bucket
+	for rows.Next() {
+		var b bucket
+		if err := rows.Scan(&b.targetID, &b.bucketTS, &b.avgRTTNs, &b.successCount, &b.failureCount); err != nil {
+			rows.Close()
+			return err
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert := fmt.Sprintf(`INSERT OR REPLACE INTO %s (target_id, bucket_ts, avg_rtt_ns, success_count, failure_count) VALUES (?, ?, ?, ?, ?)`, dstTable)
+	deleteBucket := fmt.Sprintf(`DELETE FROM %s WHERE target_id = ? AND (%s / ?) * ? = ?`, srcTable, tsCol)
+
+	for _, b := range buckets {
+		if _, err := tx.Exec(insert, b.targetID, b.bucketTS, int64(b.avgRTTNs), b.successCount, b.failureCount); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(deleteBucket, b.targetID, bucketNs, bucketNs, b.bucketTS); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
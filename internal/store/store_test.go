@@ -0,0 +1,58 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDownsampleRollsUpClosedBucketOnly(t *testing.T) {
+	s, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	now := time.Now()
+	bucketStart := now.Add(-rawRetention - time.Hour).Truncate(time.Minute)
+
+	// Two samples in a bucket old enough to be fully closed: one success,
+	// one failure.
+	if err := s.Record("t1", bucketStart, 10*time.Millisecond, true, ""); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	if err := s.Record("t1", bucketStart.Add(20*time.Second), 30*time.Millisecond, false, "timeout"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	// One sample in a bucket still within the retention window, which
+	// must be left alone.
+	if err := s.Record("t1", now, 5*time.Millisecond, true, ""); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	if err := s.Downsample(now); err != nil {
+		t.Fatalf("Downsample: %v", err)
+	}
+
+	agg, err := s.Query("t1", bucketStart.Add(-time.Minute), bucketStart.Add(time.Minute), Resolution1Minute)
+	if err != nil {
+		t.Fatalf("Query agg_1m: %v", err)
+	}
+	if len(agg) != 1 {
+		t.Fatalf("expected 1 aggregated bucket, got %d", len(agg))
+	}
+	if got := agg[0].SuccessCount; got != 1 {
+		t.Errorf("SuccessCount = %d, want 1", got)
+	}
+	if got := agg[0].FailureCount; got != 1 {
+		t.Errorf("FailureCount = %d, want 1", got)
+	}
+
+	raw, err := s.Query("t1", bucketStart.Add(-time.Minute), now.Add(time.Minute), ResolutionRaw)
+	if err != nil {
+		t.Fatalf("Query raw: %v", err)
+	}
+	if len(raw) != 1 {
+		t.Fatalf("expected the still-open bucket's 1 raw sample to remain, got %d", len(raw))
+	}
+}